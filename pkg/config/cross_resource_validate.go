@@ -0,0 +1,198 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// identitySettingNames are the settings whose value must be globally unique
+// across the whole blueprint, mirroring how GCP itself scopes these
+// identifiers (e.g. two VPC networks in the same project cannot share a
+// name). Resource.Kind only ever holds the generic module mechanism
+// ("terraform"/"packer"), not the module's identity, so uniqueness is
+// checked by setting name rather than gated on Kind.
+var identitySettingNames = []string{"network_name", "account_id", "name"}
+
+// identitySettingScopes maps an identity setting name to the module-family
+// substrings of Resource.Source that the setting actually scopes, e.g.
+// community/modules/network/vpc or modules/network/pre-existing-vpc. "name"
+// in particular is set by many unrelated module families (a compute
+// instance, a firewall rule, ...) whose names GCP scopes in entirely
+// separate namespaces, so the uniqueness check must only run for the module
+// families where a collision would be real.
+var identitySettingScopes = map[string][]string{
+	"network_name": {"/network/"},
+	"account_id":   {"/service-account/"},
+	"name":         {"/network/vpc", "/bucket/", "/service-account/"},
+}
+
+// inIdentitySettingScope reports whether source belongs to one of the
+// module families identitySettingScopes lists for settingName.
+func inIdentitySettingScope(settingName, source string) bool {
+	for _, substr := range identitySettingScopes[settingName] {
+		if strings.Contains(source, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+type cidrClaim struct {
+	resID string
+	cidr  string
+}
+
+// validateCrossResourceReferences runs after validateResources and
+// validateResourceSettings and walks the whole DAG for structural issues
+// that per-resource checks cannot see on their own: duplicate resource IDs,
+// duplicate globally-unique identity settings, overlapping subnet CIDRs
+// within a region, and ((res.X.out)) references that point at a resource in
+// a later group or at an output the producer never declared. Every lookup
+// map is built once and every collision is reported in a single pass rather
+// than stopping at the first.
+func (bc BlueprintConfig) validateCrossResourceReferences() Diagnostics {
+	var diags Diagnostics
+
+	seenIDs := map[string]string{}               // resource ID -> "group (ID)" it was first seen in
+	groupIndex := map[string]int{}               // resource ID -> index of its group
+	identities := map[string]map[string]string{} // setting name -> identity value -> owning resource ID
+	cidrsByRegion := map[string][]cidrClaim{}
+
+	for gi, grp := range bc.Config.ResourceGroups {
+		for _, res := range grp.Resources {
+			groupIndex[res.ID] = gi
+
+			if first, ok := seenIDs[res.ID]; ok {
+				diags.Add(SeverityError,
+					fmt.Sprintf("resource_groups[%s].resources[%s]", grp.Name, res.ID),
+					fmt.Sprintf("duplicate resource ID %q: already used by %s", res.ID, first),
+					"resource IDs must be unique across the whole blueprint")
+			} else {
+				seenIDs[res.ID] = fmt.Sprintf("%s (%s)", grp.Name, res.ID)
+			}
+
+			for _, settingName := range identitySettingNames {
+				if !inIdentitySettingScope(settingName, res.Source) {
+					continue
+				}
+				value, ok := res.Settings[settingName].(string)
+				if !ok || value == "" {
+					continue
+				}
+				if identities[settingName] == nil {
+					identities[settingName] = map[string]string{}
+				}
+				if owner, claimed := identities[settingName][value]; claimed {
+					diags.Add(SeverityError,
+						fmt.Sprintf("resource_groups[%s].resources[%s].settings.%s", grp.Name, res.ID, settingName),
+						fmt.Sprintf("%s %q is also claimed by module %s", settingName, value, owner),
+						"this value must be unique across the whole blueprint")
+				} else {
+					identities[settingName][value] = res.ID
+				}
+			}
+
+			if region, cidr, ok := subnetCIDR(res); ok {
+				for _, other := range cidrsByRegion[region] {
+					if cidrsOverlap(cidr, other.cidr) {
+						diags.Add(SeverityError,
+							fmt.Sprintf("resource_groups[%s].resources[%s].settings.ip_cidr_range", grp.Name, res.ID),
+							fmt.Sprintf("CIDR %s overlaps with %s claimed by module %s in region %s", cidr, other.cidr, other.resID, region),
+							"")
+					}
+				}
+				cidrsByRegion[region] = append(cidrsByRegion[region], cidrClaim{resID: res.ID, cidr: cidr})
+			}
+		}
+	}
+
+	for gi, grp := range bc.Config.ResourceGroups {
+		for _, res := range grp.Resources {
+			for settingName, value := range res.Settings {
+				ref, ok := value.(string)
+				if !ok || !IsLiteralVariable(ref) {
+					continue
+				}
+				body := HandleLiteralVariable(ref)
+				parts := strings.Split(body, ".")
+				if len(parts) != 3 || parts[0] != "res" {
+					continue
+				}
+				producerID, output := parts[1], parts[2]
+				path := fmt.Sprintf("resource_groups[%s].resources[%s].settings.%s", grp.Name, res.ID, settingName)
+
+				producerGroup, ok := groupIndex[producerID]
+				if !ok {
+					diags.Add(SeverityError, path,
+						fmt.Sprintf("((res.%s.%s)) refers to a resource ID that does not exist", producerID, output), "")
+					continue
+				}
+				if producerGroup > gi {
+					diags.Add(SeverityError, path,
+						fmt.Sprintf("((res.%s.%s)) refers to a resource in a later group", producerID, output),
+						"resource groups are applied in order; move the producer to an earlier group")
+					continue
+				}
+				if !bc.outputDeclared(producerGroup, producerID, output) {
+					diags.Add(SeverityError, path,
+						fmt.Sprintf("((res.%s.%s)) refers to an output not declared in that module's outputs list", producerID, output), "")
+				}
+			}
+		}
+	}
+
+	return diags
+}
+
+func subnetCIDR(res Resource) (region, cidr string, ok bool) {
+	cidrVal, hasCIDR := res.Settings["ip_cidr_range"].(string)
+	regionVal, hasRegion := res.Settings["region"].(string)
+	if !hasCIDR || !hasRegion {
+		return "", "", false
+	}
+	return regionVal, cidrVal, true
+}
+
+func cidrsOverlap(a, b string) bool {
+	_, netA, errA := net.ParseCIDR(a)
+	_, netB, errB := net.ParseCIDR(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return netA.Contains(netB.IP) || netB.Contains(netA.IP)
+}
+
+// outputDeclared reports whether resID in the group at groupIdx declares
+// output among its Outputs.
+func (bc BlueprintConfig) outputDeclared(groupIdx int, resID, output string) bool {
+	grp := bc.Config.ResourceGroups[groupIdx]
+	for _, res := range grp.Resources {
+		if res.ID != resID {
+			continue
+		}
+		for _, declared := range res.Outputs {
+			if declared == output {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
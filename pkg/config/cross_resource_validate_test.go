@@ -0,0 +1,88 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import "testing"
+
+func TestCIDRsOverlap(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"identical", "10.0.0.0/24", "10.0.0.0/24", true},
+		{"nested", "10.0.0.0/16", "10.0.1.0/24", true},
+		{"disjoint", "10.0.0.0/24", "10.0.1.0/24", false},
+		{"invalid a", "not-a-cidr", "10.0.0.0/24", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := cidrsOverlap(tc.a, tc.b); got != tc.want {
+				t.Errorf("cidrsOverlap(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateCrossResourceReferencesDuplicateSubnetCIDR(t *testing.T) {
+	bc := BlueprintConfig{Config: YamlConfig{ResourceGroups: []ResourceGroup{
+		{Name: "group1", Resources: []Resource{
+			{ID: "subnet1", Settings: map[string]interface{}{"region": "us-east1", "ip_cidr_range": "10.0.0.0/24"}},
+			{ID: "subnet2", Settings: map[string]interface{}{"region": "us-east1", "ip_cidr_range": "10.0.0.0/25"}},
+		}},
+	}}}
+
+	diags := bc.validateCrossResourceReferences()
+	if !diags.HasErrors() {
+		t.Fatalf("expected overlapping subnet CIDRs in the same region to be reported, got: %v", diags)
+	}
+}
+
+func TestValidateCrossResourceReferencesNameScopedByModuleFamily(t *testing.T) {
+	// A compute instance and a firewall rule both happen to set name to
+	// "default". GCP scopes those names in separate namespaces, and neither
+	// source belongs to a module family identitySettingScopes lists for
+	// "name", so this must not be flagged as a conflict.
+	bc := BlueprintConfig{Config: YamlConfig{ResourceGroups: []ResourceGroup{
+		{Name: "group1", Resources: []Resource{
+			{ID: "vm1", Source: "modules/compute/vm-instance", Settings: map[string]interface{}{"name": "default"}},
+			{ID: "fw1", Source: "modules/network/firewall-rule", Settings: map[string]interface{}{"name": "default"}},
+		}},
+	}}}
+
+	diags := bc.validateCrossResourceReferences()
+	if diags.HasErrors() {
+		t.Fatalf("expected no conflict for \"name\" across unrelated module families, got: %v", diags)
+	}
+}
+
+func TestValidateCrossResourceReferencesDuplicateNameWithinScope(t *testing.T) {
+	// Two VPC networks claiming the same name are a real conflict: both
+	// sources are in the "/network/vpc" family that identitySettingScopes
+	// lists for "name".
+	bc := BlueprintConfig{Config: YamlConfig{ResourceGroups: []ResourceGroup{
+		{Name: "group1", Resources: []Resource{
+			{ID: "net1", Source: "community/modules/network/vpc", Settings: map[string]interface{}{"name": "shared-vpc"}},
+			{ID: "net2", Source: "community/modules/network/vpc", Settings: map[string]interface{}{"name": "shared-vpc"}},
+		}},
+	}}}
+
+	diags := bc.validateCrossResourceReferences()
+	if !diags.HasErrors() {
+		t.Fatalf("expected a duplicate \"name\" conflict within the network module family, got: %v", diags)
+	}
+}
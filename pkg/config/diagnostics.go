@@ -0,0 +1,208 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Severity classifies how serious a Diagnostic is. Error-severity
+// diagnostics are the only ones that fail validation.
+type Severity int
+
+// The supported diagnostic severities, ordered from most to least serious.
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "ERROR"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityInfo:
+		return "INFO"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Diagnostic is a single validation finding. Path identifies where in the
+// blueprint the finding applies (e.g. "vars.project_id" or
+// "resource_groups[0].resources[2].settings.machine_type"), Message is the
+// human-readable description, and Hint is optional remediation advice.
+type Diagnostic struct {
+	Severity Severity
+	Path     string
+	Message  string
+	Hint     string
+}
+
+func (d Diagnostic) String() string {
+	if d.Hint == "" {
+		return fmt.Sprintf("%s: %s: %s", d.Severity, d.Path, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", d.Severity, d.Path, d.Message, d.Hint)
+}
+
+// Diagnostics is an accumulated list of validation findings gathered across
+// vars, validators, resources, outputs, and settings, so that a single run
+// can surface every problem rather than stopping at the first one.
+type Diagnostics []Diagnostic
+
+// Add appends a new diagnostic.
+func (ds *Diagnostics) Add(severity Severity, path, message, hint string) {
+	*ds = append(*ds, Diagnostic{Severity: severity, Path: path, Message: message, Hint: hint})
+}
+
+// Extend appends every diagnostic from other.
+func (ds *Diagnostics) Extend(other Diagnostics) {
+	*ds = append(*ds, other...)
+}
+
+// HasErrors reports whether any Error-severity diagnostic remains.
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyLevel downgrades Error-severity diagnostics to Warning or Info
+// according to the blueprint's configured ValidationLevel, mirroring the
+// legacy --validation-level flag semantics.
+func (ds Diagnostics) ApplyLevel(level int) Diagnostics {
+	out := make(Diagnostics, len(ds))
+	for i, d := range ds {
+		if d.Severity == SeverityError {
+			switch level {
+			case validationWarning:
+				d.Severity = SeverityWarning
+			case validationIgnore:
+				d.Severity = SeverityInfo
+			}
+		}
+		out[i] = d
+	}
+	return out
+}
+
+// Render renders diagnostics in the given format ("text", "json", or
+// "sarif"; "" means "text"). No ghpc command-line flag selects the format.
+func (ds Diagnostics) Render(format string) (string, error) {
+	switch format {
+	case "", "text":
+		return ds.Text(), nil
+	case "json":
+		b, err := ds.JSON()
+		return string(b), err
+	case "sarif":
+		b, err := ds.SARIF()
+		return string(b), err
+	default:
+		return "", fmt.Errorf("unknown diagnostics format %q, must be one of: text, json, sarif", format)
+	}
+}
+
+// Text renders diagnostics as plain, human-readable text, one per line.
+func (ds Diagnostics) Text() string {
+	lines := make([]string, len(ds))
+	for i, d := range ds {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// JSON renders diagnostics as a JSON array for machine consumption.
+func (ds Diagnostics) JSON() ([]byte, error) {
+	type jsonDiagnostic struct {
+		Severity string `json:"severity"`
+		Path     string `json:"path"`
+		Message  string `json:"message"`
+		Hint     string `json:"hint,omitempty"`
+	}
+	out := make([]jsonDiagnostic, len(ds))
+	for i, d := range ds {
+		out[i] = jsonDiagnostic{Severity: d.Severity.String(), Path: d.Path, Message: d.Message, Hint: d.Hint}
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// sarifLevel maps a Severity onto the SARIF "level" property.
+func (s Severity) sarifLevel() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// SARIF renders diagnostics as a minimal SARIF 2.1.0 log so that CI systems
+// (e.g. GitHub code scanning) can ingest blueprint validation results.
+func (ds Diagnostics) SARIF() ([]byte, error) {
+	type sarifLocation struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+		} `json:"physicalLocation"`
+	}
+	type sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   struct{ Text string `json:"text"` } `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	results := make([]sarifResult, len(ds))
+	for i, d := range ds {
+		r := sarifResult{RuleID: "ghpc-blueprint-validate", Level: d.Severity.sarifLevel()}
+		r.Message.Text = d.Message
+		if d.Hint != "" {
+			r.Message.Text = fmt.Sprintf("%s (%s)", d.Message, d.Hint)
+		}
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = d.Path
+		r.Locations = []sarifLocation{loc}
+		results[i] = r
+	}
+
+	doc := map[string]interface{}{
+		"version": "2.1.0",
+		"$schema": "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		"runs": []map[string]interface{}{
+			{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{
+						"name": "ghpc",
+					},
+				},
+				"results": results,
+			},
+		},
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,67 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticsJSON(t *testing.T) {
+	var diags Diagnostics
+	diags.Add(SeverityError, "vars.project_id", "value is required", "")
+
+	b, err := diags.JSON()
+	if err != nil {
+		t.Fatalf("JSON() returned error: %v", err)
+	}
+
+	var decoded []map[string]string
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("JSON() output did not parse as JSON: %v", err)
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(decoded))
+	}
+	if decoded[0]["severity"] != "ERROR" || decoded[0]["path"] != "vars.project_id" {
+		t.Errorf("unexpected diagnostic: %+v", decoded[0])
+	}
+}
+
+func TestDiagnosticsSARIF(t *testing.T) {
+	var diags Diagnostics
+	diags.Add(SeverityWarning, "vars.region", "deprecated region", "")
+
+	b, err := diags.SARIF()
+	if err != nil {
+		t.Fatalf("SARIF() returned error: %v", err)
+	}
+	if !json.Valid(b) {
+		t.Fatalf("SARIF() did not produce valid JSON: %s", b)
+	}
+	if !strings.Contains(string(b), `"level": "warning"`) {
+		t.Errorf("expected SARIF output to map SeverityWarning to level \"warning\", got: %s", b)
+	}
+}
+
+func TestDiagnosticsRenderUnknownFormat(t *testing.T) {
+	var diags Diagnostics
+	if _, err := diags.Render("xml"); err == nil {
+		t.Error("expected Render with an unknown format to return an error")
+	}
+}
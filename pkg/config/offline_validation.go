@@ -0,0 +1,191 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Blueprint vars that configure offline validation. These live alongside
+// project_id and labels in vars: rather than as dedicated YAML fields, the
+// same convention secret_refs (see secrets.go) uses to stay within the
+// existing map[string]interface{} shape of Config.Vars.
+const (
+	// validationModeVarKey set to "offline" makes the project, region, zone
+	// and zone-in-region checks consult a local metadata cache instead of
+	// calling the live API, for use in air-gapped CI or unit tests. The other
+	// built-in checks (validators_gcp.go) call the live API in either mode.
+	validationModeVarKey = "validation_mode"
+	// metadataCachePathVarKey overrides the default cache location of
+	// ~/.config/ghpc/gcp-metadata.json.
+	metadataCachePathVarKey = "metadata_cache_path"
+	// metadataCacheTTLHoursVarKey overrides defaultCacheTTL.
+	metadataCacheTTLHoursVarKey = "metadata_cache_ttl_hours"
+)
+
+// defaultCacheTTL is how long a metadata cache is trusted before
+// executeValidators attaches a staleness warning diagnostic.
+const defaultCacheTTL = 24 * time.Hour
+
+// MetadataSource answers the same existence questions as the live API calls
+// in pkg/validators, but from a local cache file prepared ahead of time, so
+// that `ghpc create` is usable offline. ghpc has no command that writes
+// this file.
+type MetadataSource interface {
+	ProjectExists(projectID string) error
+	RegionExists(projectID, region string) error
+	ZoneExists(projectID, zone string) error
+	ZoneInRegion(projectID, zone, region string) error
+	FetchedAt() (time.Time, error)
+}
+
+// gcpMetadataCache is the on-disk shape of the metadata cache file read by
+// cachedMetadataSource.
+type gcpMetadataCache struct {
+	FetchedAt     string              `json:"fetched_at"`
+	Projects      []string            `json:"projects"`
+	Regions       map[string][]string `json:"regions"`         // project -> regions
+	Zones         map[string][]string `json:"zones"`           // project -> zones
+	ZonesByRegion map[string][]string `json:"zones_by_region"` // "project/region" -> zones
+}
+
+// defaultCachePath returns ~/.config/ghpc/gcp-metadata.json.
+func defaultCachePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory for the offline metadata cache: %w", err)
+	}
+	return filepath.Join(home, ".config", "ghpc", "gcp-metadata.json"), nil
+}
+
+type cachedMetadataSource struct {
+	cache *gcpMetadataCache
+}
+
+// loadMetadataSource reads and parses the metadata cache file, defaulting
+// to defaultCachePath() when path is "".
+func loadMetadataSource(path string) (MetadataSource, error) {
+	if path == "" {
+		var err error
+		path, err = defaultCachePath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offline gcp metadata cache at %s: %w", path, err)
+	}
+	var cache gcpMetadataCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("failed to parse offline gcp metadata cache at %s: %w", path, err)
+	}
+	return cachedMetadataSource{cache: &cache}, nil
+}
+
+func stringSliceContains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (s cachedMetadataSource) ProjectExists(projectID string) error {
+	if !stringSliceContains(s.cache.Projects, projectID) {
+		return fmt.Errorf("project %s not found in offline metadata cache", projectID)
+	}
+	return nil
+}
+
+func (s cachedMetadataSource) RegionExists(projectID, region string) error {
+	if !stringSliceContains(s.cache.Regions[projectID], region) {
+		return fmt.Errorf("region %s not found in offline metadata cache for project %s", region, projectID)
+	}
+	return nil
+}
+
+func (s cachedMetadataSource) ZoneExists(projectID, zone string) error {
+	if !stringSliceContains(s.cache.Zones[projectID], zone) {
+		return fmt.Errorf("zone %s not found in offline metadata cache for project %s", zone, projectID)
+	}
+	return nil
+}
+
+func (s cachedMetadataSource) ZoneInRegion(projectID, zone, region string) error {
+	key := projectID + "/" + region
+	if !stringSliceContains(s.cache.ZonesByRegion[key], zone) {
+		return fmt.Errorf("zone %s not found in region %s for project %s in offline metadata cache", zone, region, projectID)
+	}
+	return nil
+}
+
+func (s cachedMetadataSource) FetchedAt() (time.Time, error) {
+	return time.Parse(time.RFC3339, s.cache.FetchedAt)
+}
+
+// isOfflineValidation reports whether this blueprint asked for
+// validation_mode: offline.
+func (bc *BlueprintConfig) isOfflineValidation() bool {
+	mode, _ := bc.Config.Vars[validationModeVarKey].(string)
+	return mode == "offline"
+}
+
+// cacheTTL returns the configured metadata_cache_ttl_hours, or
+// defaultCacheTTL if it was not set.
+func (bc *BlueprintConfig) cacheTTL() time.Duration {
+	hours, ok := toFloat(bc.Config.Vars[metadataCacheTTLHoursVarKey])
+	if !ok || hours <= 0 {
+		return defaultCacheTTL
+	}
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// metadataSource loads the MetadataSource configured for this blueprint via
+// metadata_cache_path, or the default cache location.
+func (bc *BlueprintConfig) metadataSource() (MetadataSource, error) {
+	path, _ := bc.Config.Vars[metadataCachePathVarKey].(string)
+	return loadMetadataSource(path)
+}
+
+// staleCacheDiagnostic returns a warning Diagnostic if the configured
+// metadata cache is older than cacheTTL, or nil if it is fresh enough (or
+// its age cannot be determined).
+func (bc *BlueprintConfig) staleCacheDiagnostic() *Diagnostic {
+	source, err := bc.metadataSource()
+	if err != nil {
+		return nil
+	}
+	fetchedAt, err := source.FetchedAt()
+	if err != nil {
+		return nil
+	}
+	if age := time.Since(fetchedAt); age > bc.cacheTTL() {
+		return &Diagnostic{
+			Severity: SeverityWarning,
+			Path:     "vars." + validationModeVarKey,
+			Message:  fmt.Sprintf("offline metadata cache is %s old, older than the configured TTL", age.Round(time.Minute)),
+			Hint:     "update the cache file and its fetched_at timestamp",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheTTLDefault(t *testing.T) {
+	bc := &BlueprintConfig{}
+	if got := bc.cacheTTL(); got != defaultCacheTTL {
+		t.Errorf("cacheTTL() = %v, want default %v", got, defaultCacheTTL)
+	}
+}
+
+func TestCacheTTLOverride(t *testing.T) {
+	bc := &BlueprintConfig{Config: YamlConfig{Vars: map[string]interface{}{
+		metadataCacheTTLHoursVarKey: float64(2),
+	}}}
+	if got, want := bc.cacheTTL(), 2*time.Hour; got != want {
+		t.Errorf("cacheTTL() = %v, want %v", got, want)
+	}
+}
+
+func writeTestCache(t *testing.T, fetchedAt time.Time) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gcp-metadata.json")
+	cache := gcpMetadataCache{FetchedAt: fetchedAt.Format(time.RFC3339)}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		t.Fatalf("failed to marshal test cache: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write test cache: %v", err)
+	}
+	return path
+}
+
+func TestStaleCacheDiagnosticStale(t *testing.T) {
+	path := writeTestCache(t, time.Now().Add(-48*time.Hour))
+	bc := &BlueprintConfig{Config: YamlConfig{Vars: map[string]interface{}{
+		metadataCachePathVarKey:     path,
+		metadataCacheTTLHoursVarKey: float64(24),
+	}}}
+
+	diag := bc.staleCacheDiagnostic()
+	if diag == nil {
+		t.Fatal("expected a staleness diagnostic for a 48h-old cache with a 24h TTL, got nil")
+	}
+	if diag.Severity != SeverityWarning {
+		t.Errorf("diagnostic severity = %v, want %v", diag.Severity, SeverityWarning)
+	}
+}
+
+func TestStaleCacheDiagnosticFresh(t *testing.T) {
+	path := writeTestCache(t, time.Now().Add(-1*time.Hour))
+	bc := &BlueprintConfig{Config: YamlConfig{Vars: map[string]interface{}{
+		metadataCachePathVarKey:     path,
+		metadataCacheTTLHoursVarKey: float64(24),
+	}}}
+
+	if diag := bc.staleCacheDiagnostic(); diag != nil {
+		t.Errorf("expected no staleness diagnostic for a 1h-old cache with a 24h TTL, got: %v", diag)
+	}
+}
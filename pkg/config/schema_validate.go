@@ -0,0 +1,258 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"hpc-toolkit/pkg/resreader"
+)
+
+// validationTagPrefix marks the line in a variable's description that
+// carries validation rules, e.g.:
+//   description: "region to deploy into\nvalidation: required,oneof=us-central1 us-east1"
+const validationTagPrefix = "validation:"
+
+// validationRule is one constraint parsed out of a module variable's
+// description, e.g. "required", "oneof=us-central1 us-east1", "cidr",
+// "min=1", "max=64", "regexp=...", "gcp_label_key", "gcp_machine_type", or
+// "gcp_zone".
+type validationRule struct {
+	tag   string
+	param string
+}
+
+// splitRuleSpec splits a validation: spec on the "," that separates rules,
+// honoring "\," as an escaped, literal comma. This lets a regexp= param
+// contain a comma, e.g. a "{1,3}" quantifier written as "{1\,3}", without it
+// being eaten as a rule separator.
+func splitRuleSpec(spec string) []string {
+	var parts []string
+	var cur strings.Builder
+	escaped := false
+	for _, r := range spec {
+		switch {
+		case escaped:
+			if r != ',' {
+				cur.WriteRune('\\')
+			}
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if escaped {
+		cur.WriteRune('\\')
+	}
+	parts = append(parts, cur.String())
+	return parts
+}
+
+// parseValidationRules extracts the validation rules embedded in a
+// variable's description, if any.
+func parseValidationRules(description string) []validationRule {
+	var rules []validationRule
+	for _, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, validationTagPrefix) {
+			continue
+		}
+		spec := strings.TrimSpace(strings.TrimPrefix(line, validationTagPrefix))
+		for _, tag := range splitRuleSpec(spec) {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				continue
+			}
+			parts := strings.SplitN(tag, "=", 2)
+			rule := validationRule{tag: parts[0]}
+			if len(parts) == 2 {
+				rule.param = parts[1]
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// multiError collects every validation failure found in a single pass
+// instead of stopping at the first one.
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// asError returns nil if no errors were collected, the lone error if there
+// is exactly one, or the combined multiError otherwise.
+func (m multiError) asError() error {
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	default:
+		return m
+	}
+}
+
+// gcpMachineTypeRegexp matches a predefined machine type family, e.g.
+// "n2-standard-4" or "a2-highgpu-1g", the no-numeric-suffix shared-core
+// families ("e2-medium", "f1-micro", "g1-small"), as well as the
+// "custom-<cpus>-<mem>" form used for custom machine types.
+var gcpMachineTypeRegexp = regexp.MustCompile(`^([a-z][a-z0-9]*-[a-z]+(-[0-9]+g?)?|custom-[0-9]+-[0-9]+)$`)
+
+// gcpZoneRegexp matches a GCP zone name, a region name plus a single
+// lowercase letter suffix, e.g. "us-central1-a".
+var gcpZoneRegexp = regexp.MustCompile(`^[a-z]+-[a-z0-9]+-[a-z]$`)
+
+// gcpLabelKeyRegexp matches a GCP label key: lowercase letters, digits,
+// underscores, and dashes, starting with a lowercase letter. This is a
+// dedicated check rather than a reuse of hasIllegalChars (validate.go),
+// whose pattern permits uppercase letters, "+", and "." and was written to
+// validate resource names, not label keys.
+var gcpLabelKeyRegexp = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// checkValidationRule applies a single parsed rule to a setting value,
+// returning a human-oriented message keyed by resource ID + setting name on
+// failure.
+func checkValidationRule(resID, name string, rule validationRule, value interface{}) error {
+	label := fmt.Sprintf("module %s, setting %s", resID, name)
+
+	switch rule.tag {
+	case "required":
+		if value == nil {
+			return fmt.Errorf("%s: value is required", label)
+		}
+	case "oneof":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string to validate against oneof", label)
+		}
+		for _, allowed := range strings.Fields(rule.param) {
+			if str == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %q must be one of %v", label, str, strings.Fields(rule.param))
+	case "min", "max":
+		num, ok := toFloat(value)
+		if !ok {
+			return fmt.Errorf("%s: must be a number to validate against %s", label, rule.tag)
+		}
+		bound, err := strconv.ParseFloat(rule.param, 64)
+		if err != nil {
+			return fmt.Errorf("%s: invalid %s bound %q", label, rule.tag, rule.param)
+		}
+		if rule.tag == "min" && num < bound {
+			return fmt.Errorf("%s: %v is below the minimum of %v", label, num, bound)
+		}
+		if rule.tag == "max" && num > bound {
+			return fmt.Errorf("%s: %v is above the maximum of %v", label, num, bound)
+		}
+	case "cidr":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string to validate as a CIDR", label)
+		}
+		if _, _, err := net.ParseCIDR(str); err != nil {
+			return fmt.Errorf("%s: %q is not a valid CIDR block", label, str)
+		}
+	case "regexp":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string to validate against regexp", label)
+		}
+		re, err := regexp.Compile(rule.param)
+		if err != nil {
+			return fmt.Errorf("%s: invalid regexp %q", label, rule.param)
+		}
+		if !re.MatchString(str) {
+			return fmt.Errorf("%s: %q does not match %s", label, str, rule.param)
+		}
+	case "gcp_label_key":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string label key", label)
+		}
+		if len(str) == 0 || len(str) > 63 || !gcpLabelKeyRegexp.MatchString(str) {
+			return fmt.Errorf("%s: %q is not a valid GCP label key (<=63 chars, [a-z0-9_-], starting with a lowercase letter)", label, str)
+		}
+	case "gcp_machine_type":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string machine type", label)
+		}
+		if !gcpMachineTypeRegexp.MatchString(str) {
+			return fmt.Errorf("%s: %q is not a valid GCP machine type family (e.g. n2-standard-4, custom-4-16384)", label, str)
+		}
+	case "gcp_zone":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string zone", label)
+		}
+		if !gcpZoneRegexp.MatchString(str) {
+			return fmt.Errorf("%s: %q is not a valid GCP zone (e.g. us-central1-a)", label, str)
+		}
+	}
+	return nil
+}
+
+// validateSettingsSchema checks every declared setting against the
+// validation rules embedded in the module's variable descriptions,
+// accumulating every failure instead of stopping at the first one.
+func validateSettingsSchema(res Resource, info resreader.ResourceInfo) error {
+	var errs multiError
+
+	for _, input := range info.Inputs {
+		rules := parseValidationRules(input.Description)
+		if len(rules) == 0 {
+			continue
+		}
+		value := res.Settings[input.Name]
+		for _, rule := range rules {
+			if err := checkValidationRule(res.ID, input.Name, rule, value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errs.asError()
+}
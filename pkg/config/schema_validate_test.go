@@ -0,0 +1,73 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import "testing"
+
+func TestParseValidationRulesRegexpWithComma(t *testing.T) {
+	description := `a machine name
+validation: required,regexp=^[a-z]{1\,3}$`
+
+	rules := parseValidationRules(description)
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d: %+v", len(rules), rules)
+	}
+	if rules[0].tag != "required" {
+		t.Errorf("rules[0].tag = %q, want \"required\"", rules[0].tag)
+	}
+	if rules[1].tag != "regexp" {
+		t.Fatalf("rules[1].tag = %q, want \"regexp\"", rules[1].tag)
+	}
+	if want := "^[a-z]{1,3}$"; rules[1].param != want {
+		t.Errorf("rules[1].param = %q, want %q", rules[1].param, want)
+	}
+}
+
+func TestCheckValidationRuleRegexp(t *testing.T) {
+	rule := validationRule{tag: "regexp", param: "^[a-z]{1,3}$"}
+
+	if err := checkValidationRule("res", "name", rule, "ab"); err != nil {
+		t.Errorf("expected \"ab\" to match, got error: %v", err)
+	}
+	if err := checkValidationRule("res", "name", rule, "abcd"); err == nil {
+		t.Error("expected \"abcd\" to fail to match, got nil error")
+	}
+}
+
+func TestCheckValidationRuleGCPMachineType(t *testing.T) {
+	rule := validationRule{tag: "gcp_machine_type"}
+
+	for _, valid := range []string{"n2-standard-4", "e2-medium", "custom-4-16384"} {
+		if err := checkValidationRule("res", "machine_type", rule, valid); err != nil {
+			t.Errorf("expected %q to be a valid machine type, got error: %v", valid, err)
+		}
+	}
+	if err := checkValidationRule("res", "machine_type", rule, "not a machine type"); err == nil {
+		t.Error("expected an invalid machine type to fail, got nil error")
+	}
+}
+
+func TestCheckValidationRuleGCPZone(t *testing.T) {
+	rule := validationRule{tag: "gcp_zone"}
+
+	if err := checkValidationRule("res", "zone", rule, "us-central1-a"); err != nil {
+		t.Errorf("expected \"us-central1-a\" to be a valid zone, got error: %v", err)
+	}
+	if err := checkValidationRule("res", "zone", rule, "us-central1"); err == nil {
+		t.Error("expected a region (not a zone) to fail, got nil error")
+	}
+}
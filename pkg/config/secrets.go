@@ -0,0 +1,234 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	secretmanagerpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+// secretReferencePrefix marks a literal like ((secret.gcp_sm:projects/p/...))
+// or ((secret.env:MY_TOKEN)) for resolution through a SecretProvider rather
+// than the var/res literal lookups that IsLiteralVariable already handles.
+const secretReferencePrefix = "secret."
+
+// secretPlaceholderKey is the name of the global var, conventionally
+// "secret_refs", that maps a var name to a secret reference. validateVars
+// accepts a var left nil in vars: if it has a matching entry here, so that
+// the secret value itself never needs to be inlined. The entry is not read
+// to fill the var in; a ((var.name)) reference to such a var still fails
+// to resolve.
+const secretPlaceholderKey = "secret_refs"
+
+// SecretProvider resolves a secret reference of the form "scheme:ref" (the
+// part of a ((secret.scheme:ref)) literal after "secret.") into its value.
+// Built-in providers cover Google Secret Manager, environment variables,
+// and a local file vault; out-of-tree packages may register their own.
+type SecretProvider interface {
+	// Scheme is the prefix used in blueprints, e.g. "gcp_sm", "env", "file".
+	Scheme() string
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+var secretProviders = map[string]SecretProvider{}
+
+// RegisterSecretProvider makes a SecretProvider available to blueprints
+// under its Scheme(). It is meant to be called from the init() function of
+// a package that ships its own secret backend.
+func RegisterSecretProvider(p SecretProvider) {
+	if _, ok := secretProviders[p.Scheme()]; ok {
+		panic(fmt.Sprintf("config: RegisterSecretProvider called twice for %s", p.Scheme()))
+	}
+	secretProviders[p.Scheme()] = p
+}
+
+func init() {
+	RegisterSecretProvider(gcpSecretManagerProvider{})
+	RegisterSecretProvider(envSecretProvider{})
+	RegisterSecretProvider(fileVaultProvider{path: os.Getenv("GHPC_SECRET_VAULT")})
+}
+
+// isSecretReference reports whether a literal variable body (the part
+// inside "((" and "))") is a secret reference rather than a var/res
+// reference.
+func isSecretReference(body string) bool {
+	return strings.HasPrefix(body, secretReferencePrefix)
+}
+
+// resolveSecretReference resolves ((secret.<scheme>:<ref>)) into its value
+// by dispatching to the registered SecretProvider for <scheme>.
+func resolveSecretReference(ctx context.Context, body string) (string, error) {
+	spec := strings.TrimPrefix(body, secretReferencePrefix)
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("secret reference %q must be of the form secret.<scheme>:<ref>", body)
+	}
+	scheme, ref := parts[0], parts[1]
+
+	provider, ok := secretProviders[scheme]
+	if !ok {
+		return "", fmt.Errorf("no secret provider registered for scheme %q", scheme)
+	}
+	return provider.Resolve(ctx, ref)
+}
+
+// SensitiveValue wraps a resolved secret so that it never appears in
+// blueprint YAML dumps (resource2String) or error messages. Callers that
+// genuinely need the value call Reveal explicitly.
+type SensitiveValue struct {
+	value string
+}
+
+// Reveal returns the underlying secret value.
+func (s SensitiveValue) Reveal() string { return s.value }
+
+// String implements fmt.Stringer, redacting the value from logs and errors.
+func (s SensitiveValue) String() string { return "((sensitive))" }
+
+// MarshalYAML redacts the value so resource2String never prints secrets.
+func (s SensitiveValue) MarshalYAML() (interface{}, error) {
+	return "((sensitive))", nil
+}
+
+// getValue resolves a setting or global variable reference, extending
+// getStringValue to additionally resolve ((secret.scheme:ref)) literals
+// through the SecretProvider registry. The returned value is a
+// SensitiveValue when it came from a secret so that it is never echoed back
+// in a diagnostic or a YAML dump. getStringValue is a thin wrapper around
+// getValue for the common case where the caller only wants the plain
+// string (e.g. every built-in validator's inputs).
+func (bc *BlueprintConfig) getValue(inputReference interface{}) (interface{}, error) {
+	varRef, ok := inputReference.(string)
+	if !ok {
+		return nil, fmt.Errorf("the value %v cannot be cast to a string", inputReference)
+	}
+
+	if IsLiteralVariable(varRef) {
+		body := HandleLiteralVariable(varRef)
+
+		if isSecretReference(body) {
+			secret, err := resolveSecretReference(context.Background(), body)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve %s: %w", varRef, err)
+			}
+			return SensitiveValue{value: secret}, nil
+		}
+
+		varSlice := strings.SplitN(body, ".", 2)
+		varSrc := varSlice[0]
+		varName := varSlice[1]
+
+		// because expand has already run, the global variable should have been
+		// checked for existence. handle if user has explicitly passed
+		// ((var.does_not_exit)) or ((not_a_varsrc.not_a_var))
+		if varSrc == "var" {
+			if val, ok := bc.Config.Vars[varName]; ok {
+				valString, ok := val.(string)
+				if ok {
+					return valString, nil
+				}
+				return nil, fmt.Errorf("the global variable %s is not a string", inputReference)
+			}
+		}
+	}
+	return nil, fmt.Errorf("the value %s is not a global variable or was not defined", inputReference)
+}
+
+// SensitiveSettings returns the names of res's settings whose value is a
+// literal secret reference, e.g. ((secret.env:MY_TOKEN)). The Terraform
+// writer does not call it, so the corresponding variables are emitted
+// without `sensitive = true`.
+func SensitiveSettings(res Resource) map[string]bool {
+	sensitive := map[string]bool{}
+	for name, val := range res.Settings {
+		str, ok := val.(string)
+		if !ok || !IsLiteralVariable(str) {
+			continue
+		}
+		if isSecretReference(HandleLiteralVariable(str)) {
+			sensitive[name] = true
+		}
+	}
+	return sensitive
+}
+
+// gcpSecretManagerProvider resolves secrets from Google Secret Manager.
+// ref is a full resource name, e.g.
+// "projects/my-project/secrets/my-secret/versions/latest".
+type gcpSecretManagerProvider struct{}
+
+func (gcpSecretManagerProvider) Scheme() string { return "gcp_sm" }
+
+func (gcpSecretManagerProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Secret Manager client: %w", err)
+	}
+	defer client.Close()
+
+	result, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: ref})
+	if err != nil {
+		return "", fmt.Errorf("failed to access secret %s: %w", ref, err)
+	}
+	return string(result.Payload.Data), nil
+}
+
+// envSecretProvider resolves secrets from the ghpc process's environment.
+// ref is the environment variable name, e.g. "MY_TOKEN".
+type envSecretProvider struct{}
+
+func (envSecretProvider) Scheme() string { return "env" }
+
+func (envSecretProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
+
+// fileVaultProvider resolves secrets from a local key=value vault file,
+// intended for offline development and CI. The vault file path is
+// configured via the GHPC_SECRET_VAULT environment variable.
+type fileVaultProvider struct {
+	path string
+}
+
+func (fileVaultProvider) Scheme() string { return "file" }
+
+func (p fileVaultProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	if p.path == "" {
+		return "", fmt.Errorf("no local secret vault configured; set GHPC_SECRET_VAULT")
+	}
+	contents, err := os.ReadFile(p.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret vault %s: %w", p.path, err)
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && parts[0] == ref {
+			return parts[1], nil
+		}
+	}
+	return "", fmt.Errorf("secret %s not found in vault %s", ref, p.path)
+}
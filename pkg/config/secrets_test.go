@@ -0,0 +1,81 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIsSecretReference(t *testing.T) {
+	if !isSecretReference("secret.env:MY_TOKEN") {
+		t.Error("expected \"secret.env:MY_TOKEN\" to be recognized as a secret reference")
+	}
+	if isSecretReference("var.project_id") {
+		t.Error("expected \"var.project_id\" not to be recognized as a secret reference")
+	}
+}
+
+func TestResolveSecretReferenceEnv(t *testing.T) {
+	t.Setenv("GHPC_TEST_TOKEN", "s3cr3t")
+
+	value, err := resolveSecretReference(context.Background(), "secret.env:GHPC_TEST_TOKEN")
+	if err != nil {
+		t.Fatalf("resolveSecretReference returned error: %v", err)
+	}
+	if value != "s3cr3t" {
+		t.Errorf("resolveSecretReference = %q, want %q", value, "s3cr3t")
+	}
+}
+
+func TestResolveSecretReferenceUnknownScheme(t *testing.T) {
+	if _, err := resolveSecretReference(context.Background(), "secret.nope:ref"); err == nil {
+		t.Error("expected an unregistered scheme to return an error")
+	}
+}
+
+// TestGetStringValueSecretReference exercises the actual call path every
+// built-in validator uses (bc.getStringValue), proving a
+// ((secret.env:...)) literal input resolves to its plaintext value instead
+// of hitting getStringValue's "not a global variable" error -- the
+// regression a758643 had to fix.
+func TestGetStringValueSecretReference(t *testing.T) {
+	t.Setenv("GHPC_TEST_TOKEN", "s3cr3t")
+
+	bc := &BlueprintConfig{}
+	value, err := bc.getStringValue("((secret.env:GHPC_TEST_TOKEN))")
+	if err != nil {
+		t.Fatalf("getStringValue returned error: %v", err)
+	}
+	if value != "s3cr3t" {
+		t.Errorf("getStringValue = %q, want %q", value, "s3cr3t")
+	}
+}
+
+func TestSensitiveValueRedacted(t *testing.T) {
+	sv := SensitiveValue{value: "s3cr3t"}
+
+	if sv.String() != "((sensitive))" {
+		t.Errorf("String() = %q, want redacted placeholder", sv.String())
+	}
+	if got, err := sv.MarshalYAML(); err != nil || got != "((sensitive))" {
+		t.Errorf("MarshalYAML() = (%v, %v), want (\"((sensitive))\", nil)", got, err)
+	}
+	if sv.Reveal() != "s3cr3t" {
+		t.Errorf("Reveal() = %q, want %q", sv.Reveal(), "s3cr3t")
+	}
+}
@@ -17,10 +17,10 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"regexp"
-	"strings"
 
 	"hpc-toolkit/pkg/resreader"
 	"hpc-toolkit/pkg/sourcereader"
@@ -30,108 +30,117 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-const (
-	validationErrorMsg = "validation failed due to the issues listed above"
-)
+// validatorTroubleshootingHint is attached to every Diagnostic produced by a
+// failed validator, since validator failures most often indicate a
+// credentials problem.
+const validatorTroubleshootingHint = "see https://github.com/GoogleCloudPlatform/hpc-toolkit/blob/main/README.md#supplying-cloud-credentials-to-terraform; " +
+	"can be downgraded with --validation-level WARNING or --validation-level IGNORE"
 
-// validate is the top-level function for running the validation suite.
-func (bc BlueprintConfig) validate() {
-	if err := bc.validateVars(); err != nil {
-		log.Fatal(err)
-	}
+// validate is the top-level function for running the validation suite. It
+// accumulates Diagnostics across every stage instead of exiting at the
+// first problem, so callers can render the complete picture at once. The
+// caller decides what to do with the result; validate itself never exits
+// the process.
+func (bc BlueprintConfig) validate() Diagnostics {
+	var diags Diagnostics
+
+	diags.Extend(bc.validateVars())
 
 	// variables should be validated before running validators
-	if err := bc.executeValidators(); err != nil {
-		log.Fatal(err)
-	}
+	diags.Extend(bc.executeValidators())
 
-	if err := bc.validateResources(); err != nil {
-		log.Fatal(err)
-	}
-	if err := bc.validateResourceSettings(); err != nil {
-		log.Fatal(err)
-	}
+	diags.Extend(bc.validateResources())
+	diags.Extend(bc.validateResourceSettings())
+
+	// runs last: it walks the whole DAG, so per-resource problems above are
+	// more useful to surface first
+	diags.Extend(bc.validateCrossResourceReferences())
+
+	// ValidationLevel governs every diagnostic, not just validator failures:
+	// executeValidators already emits its own findings at the right
+	// severity, but vars/resources/settings/cross-resource findings above
+	// are always raised as errors, so downgrade them here too.
+	return diags.ApplyLevel(bc.Config.ValidationLevel)
 }
 
-// performs validation of global variables
-func (bc BlueprintConfig) executeValidators() error {
-	var errored, warned bool
+// executeValidators runs every configured validator and turns failures into
+// Diagnostics at a severity driven by bc.Config.ValidationLevel.
+func (bc BlueprintConfig) executeValidators() Diagnostics {
+	var diags Diagnostics
 	implementedValidators := bc.getValidators()
 
 	if bc.Config.ValidationLevel == validationIgnore {
-		return nil
+		return diags
+	}
+
+	if bc.isOfflineValidation() {
+		if staleness := bc.staleCacheDiagnostic(); staleness != nil {
+			diags = append(diags, *staleness)
+		}
+	}
+
+	severity := SeverityError
+	if bc.Config.ValidationLevel == validationWarning {
+		severity = SeverityWarning
 	}
 
 	for _, validator := range bc.Config.Validators {
-		if f, ok := implementedValidators[validator.Validator]; ok {
-			err := f(validator)
+		path := fmt.Sprintf("validators[%s]", validator.Validator)
+		runValidator := implementedValidators[validator.Validator]
+		if runValidator == nil && (validator.Validator == "exec" || validator.Validator == "http") {
+			declared, err := newDeclaredValidator(validator)
 			if err != nil {
-				var prefix string
-				switch bc.Config.ValidationLevel {
-				case validationWarning:
-					warned = true
-					prefix = "warning: "
-				default:
-					errored = true
-					prefix = "error: "
-				}
-				log.Print(prefix, err)
-				log.Println()
+				diags.Add(SeverityError, path, err.Error(), "")
+				continue
+			}
+			runValidator = func(vc validatorConfig) error {
+				return declared.Run(context.Background(), vc.Inputs)
 			}
-		} else {
-			errored = true
-			log.Printf("%s is not an implemented validator", validator.Validator)
 		}
-	}
 
-	if warned || errored {
-		log.Println("validator failures can indicate a credentials problem.")
-		log.Println("troubleshooting info appears at:")
-		log.Println()
-		log.Println("https://github.com/GoogleCloudPlatform/hpc-toolkit/blob/main/README.md#supplying-cloud-credentials-to-terraform")
-		log.Println()
-		log.Println("validation can be configured:")
-		log.Println("- treat failures as warnings by using the create command")
-		log.Println("  with the flag \"--validation-level WARNING\"")
-		log.Println("- can be disabled entirely by using the create command")
-		log.Println("  with the flag \"--validation-level IGNORE\"")
-		log.Println("- a custom set of validators can be configured following")
-		log.Println("  instructions at:")
-		log.Println()
-		log.Println("https://github.com/GoogleCloudPlatform/hpc-toolkit/blob/main/README.md#blueprint-warnings-and-errors")
-	}
+		if runValidator == nil {
+			diags.Add(SeverityError, path, fmt.Sprintf("%s is not an implemented validator", validator.Validator), "")
+			continue
+		}
 
-	if errored {
-		return fmt.Errorf(validationErrorMsg)
+		if err := runValidator(validator); err != nil {
+			diags.Add(severity, path, err.Error(), validatorTroubleshootingHint)
+		}
 	}
-	return nil
+
+	return diags
 }
 
-// validateVars checks the global variables for viable types
-func (bc BlueprintConfig) validateVars() error {
+// validateVars checks the global variables for viable types.
+func (bc BlueprintConfig) validateVars() Diagnostics {
+	var diags Diagnostics
 	vars := bc.Config.Vars
-	nilErr := "global variable %s was not set"
 
 	// Check for project_id
 	if _, ok := vars["project_id"]; !ok {
-		log.Println("WARNING: No project_id in global variables")
+		diags.Add(SeverityWarning, "vars.project_id", "no project_id in global variables", "")
 	}
 
 	// Check type of labels (if they are defined)
 	if labels, ok := vars["labels"]; ok {
 		if _, ok := labels.(map[string]interface{}); !ok {
-			return errors.New("vars.labels must be a map")
+			diags.Add(SeverityError, "vars.labels", "vars.labels must be a map", "")
 		}
 	}
 
-	// Check for any nil values
+	// Check for any nil values, except placeholders that have a secret_refs
+	// entry (see secrets.go).
+	secretRefs, _ := vars[secretPlaceholderKey].(map[string]interface{})
 	for key, val := range vars {
 		if val == nil {
-			return fmt.Errorf(nilErr, key)
+			if _, ok := secretRefs[key]; ok {
+				continue
+			}
+			diags.Add(SeverityError, fmt.Sprintf("vars.%s", key), fmt.Sprintf("global variable %s was not set", key), "")
 		}
 	}
 
-	return nil
+	return diags
 }
 
 func resource2String(c Resource) string {
@@ -174,20 +183,25 @@ func validateOutputs(res Resource, resInfo resreader.ResourceInfo) error {
 	return nil
 }
 
-// validateResources ensures parameters set in resources are set correctly.
-func (bc BlueprintConfig) validateResources() error {
+// validateResources ensures parameters set in resources are set correctly,
+// collecting a Diagnostic for every resource with a problem rather than
+// stopping at the first one.
+func (bc BlueprintConfig) validateResources() Diagnostics {
+	var diags Diagnostics
 	for _, grp := range bc.Config.ResourceGroups {
 		for _, res := range grp.Resources {
+			path := fmt.Sprintf("resource_groups[%s].resources[%s]", grp.Name, res.ID)
 			if err := validateResource(res); err != nil {
-				return err
+				diags.Add(SeverityError, path, err.Error(), "")
+				continue
 			}
 			resInfo := bc.ResourcesInfo[grp.Name][res.Source]
 			if err := validateOutputs(res, resInfo); err != nil {
-				return err
+				diags.Add(SeverityError, path, err.Error(), "")
 			}
 		}
 	}
-	return nil
+	return diags
 }
 
 type resourceVariables struct {
@@ -218,32 +232,56 @@ func validateSettings(
 }
 
 // validateResourceSettings verifies that no additional settings are provided
-// that don't have a counterpart variable in the resource.
-func (bc BlueprintConfig) validateResourceSettings() error {
+// that don't have a counterpart variable in the resource, and that declared
+// settings satisfy their schema, collecting a Diagnostic per problem found.
+func (bc BlueprintConfig) validateResourceSettings() Diagnostics {
+	var diags Diagnostics
 	for _, grp := range bc.Config.ResourceGroups {
 		for _, res := range grp.Resources {
+			path := fmt.Sprintf("resource_groups[%s].resources[%s].settings", grp.Name, res.ID)
+
 			reader := sourcereader.Factory(res.Source)
 			info, err := reader.GetResourceInfo(res.Source, res.Kind)
 			if err != nil {
 				errStr := "failed to get info for module at %s while validating module settings"
-				return errors.Wrapf(err, errStr, res.Source)
+				diags.Add(SeverityError, path, errors.Wrapf(err, errStr, res.Source).Error(), "")
+				continue
 			}
 			if err = validateSettings(res, info); err != nil {
 				errStr := "found an issue while validating settings for module at %s"
-				return errors.Wrapf(err, errStr, res.Source)
+				diags.Add(SeverityError, path, errors.Wrapf(err, errStr, res.Source).Error(), "")
+			}
+			if err = validateSettingsSchema(res, info); err != nil {
+				errStr := "found an issue while validating settings schema for module at %s"
+				diags.Add(SeverityError, path, errors.Wrapf(err, errStr, res.Source).Error(), "")
 			}
 		}
 	}
-	return nil
+	return diags
 }
 
+// getValidators builds the set of validators available to this blueprint:
+// the built-in GCP checks plus any contributed by out-of-tree packages via
+// RegisterValidator. validator entries of "exec" and "http" are handled
+// separately by executeValidators since their identity comes from the
+// command/url declared in the blueprint rather than from this map.
 func (bc *BlueprintConfig) getValidators() map[string]func(validatorConfig) error {
-	allValidators := map[string]func(validatorConfig) error{
-		testProjectExistsName.String(): bc.testProjectExists,
-		testRegionExistsName.String():  bc.testRegionExists,
-		testZoneExistsName.String():    bc.testZoneExists,
-		testZoneInRegionName.String():  bc.testZoneInRegion,
+	allValidators := map[string]func(validatorConfig) error{}
+
+	for _, v := range bc.builtinValidators() {
+		v := v
+		allValidators[v.Name()] = func(vc validatorConfig) error {
+			return v.Run(context.Background(), vc.Inputs)
+		}
+	}
+
+	for name, factory := range externalValidators {
+		v := factory(bc)
+		allValidators[name] = func(vc validatorConfig) error {
+			return v.Run(context.Background(), vc.Inputs)
+		}
 	}
+
 	return allValidators
 }
 
@@ -281,22 +319,29 @@ func (bc *BlueprintConfig) testProjectExists(validator validatorConfig) error {
 
 	err := testInputList(validator.Validator, validator.Inputs, requiredInputs)
 	if err != nil {
-		log.Print(funcErrorMsg)
 		return err
 	}
 
 	projectID, err := bc.getStringValue(validator.Inputs["project_id"])
 	if err != nil {
-		log.Print(funcErrorMsg)
 		return err
 	}
 
-	// err is nil or an error
-	err = validators.TestProjectExists(projectID)
-	if err != nil {
-		log.Print(funcErrorMsg)
+	if bc.isOfflineValidation() {
+		source, err := bc.metadataSource()
+		if err != nil {
+			return errors.Wrap(err, funcErrorMsg)
+		}
+		if err := source.ProjectExists(projectID); err != nil {
+			return errors.Wrap(err, funcErrorMsg)
+		}
+		return nil
 	}
-	return err
+
+	if err := validators.TestProjectExists(projectID); err != nil {
+		return errors.Wrap(err, funcErrorMsg)
+	}
+	return nil
 }
 
 func (bc *BlueprintConfig) testRegionExists(validator validatorConfig) error {
@@ -315,21 +360,28 @@ func (bc *BlueprintConfig) testRegionExists(validator validatorConfig) error {
 
 	projectID, err := bc.getStringValue(validator.Inputs["project_id"])
 	if err != nil {
-		log.Print(funcErrorMsg)
 		return err
 	}
 	region, err := bc.getStringValue(validator.Inputs["region"])
 	if err != nil {
-		log.Print(funcErrorMsg)
 		return err
 	}
 
-	// err is nil or an error
-	err = validators.TestRegionExists(projectID, region)
-	if err != nil {
-		log.Print(funcErrorMsg)
+	if bc.isOfflineValidation() {
+		source, err := bc.metadataSource()
+		if err != nil {
+			return errors.Wrap(err, funcErrorMsg)
+		}
+		if err := source.RegionExists(projectID, region); err != nil {
+			return errors.Wrap(err, funcErrorMsg)
+		}
+		return nil
+	}
+
+	if err := validators.TestRegionExists(projectID, region); err != nil {
+		return errors.Wrap(err, funcErrorMsg)
 	}
-	return err
+	return nil
 }
 
 func (bc *BlueprintConfig) testZoneExists(validator validatorConfig) error {
@@ -348,21 +400,28 @@ func (bc *BlueprintConfig) testZoneExists(validator validatorConfig) error {
 
 	projectID, err := bc.getStringValue(validator.Inputs["project_id"])
 	if err != nil {
-		log.Print(funcErrorMsg)
 		return err
 	}
 	zone, err := bc.getStringValue(validator.Inputs["zone"])
 	if err != nil {
-		log.Print(funcErrorMsg)
 		return err
 	}
 
-	// err is nil or an error
-	err = validators.TestZoneExists(projectID, zone)
-	if err != nil {
-		log.Print(funcErrorMsg)
+	if bc.isOfflineValidation() {
+		source, err := bc.metadataSource()
+		if err != nil {
+			return errors.Wrap(err, funcErrorMsg)
+		}
+		if err := source.ZoneExists(projectID, zone); err != nil {
+			return errors.Wrap(err, funcErrorMsg)
+		}
+		return nil
 	}
-	return err
+
+	if err := validators.TestZoneExists(projectID, zone); err != nil {
+		return errors.Wrap(err, funcErrorMsg)
+	}
+	return nil
 }
 
 func (bc *BlueprintConfig) testZoneInRegion(validator validatorConfig) error {
@@ -381,55 +440,51 @@ func (bc *BlueprintConfig) testZoneInRegion(validator validatorConfig) error {
 
 	projectID, err := bc.getStringValue(validator.Inputs["project_id"])
 	if err != nil {
-		log.Print(funcErrorMsg)
 		return err
 	}
 	zone, err := bc.getStringValue(validator.Inputs["zone"])
 	if err != nil {
-		log.Print(funcErrorMsg)
 		return err
 	}
 	region, err := bc.getStringValue(validator.Inputs["region"])
 	if err != nil {
-		log.Print(funcErrorMsg)
 		return err
 	}
 
-	// err is nil or an error
-	err = validators.TestZoneInRegion(projectID, zone, region)
-	if err != nil {
-		log.Print(funcErrorMsg)
+	if bc.isOfflineValidation() {
+		source, err := bc.metadataSource()
+		if err != nil {
+			return errors.Wrap(err, funcErrorMsg)
+		}
+		if err := source.ZoneInRegion(projectID, zone, region); err != nil {
+			return errors.Wrap(err, funcErrorMsg)
+		}
+		return nil
 	}
-	return err
+
+	if err := validators.TestZoneInRegion(projectID, zone, region); err != nil {
+		return errors.Wrap(err, funcErrorMsg)
+	}
+	return nil
 }
 
 // return the actual value of a global variable specified by the literal
-// variable inputReference in form ((var.project_id))
+// variable inputReference in form ((var.project_id)), or the resolved value
+// of a ((secret.scheme:ref)) reference (see secrets.go).
 // if it is a literal global variable defined as a string, return value as string
 // in all other cases, return empty string and error
 func (bc *BlueprintConfig) getStringValue(inputReference interface{}) (string, error) {
-	varRef, ok := inputReference.(string)
-	if !ok {
-		return "", fmt.Errorf("the value %s cannot be cast to a string", inputReference)
-	}
-
-	if IsLiteralVariable(varRef) {
-		varSlice := strings.Split(HandleLiteralVariable(varRef), ".")
-		varSrc := varSlice[0]
-		varName := varSlice[1]
-
-		// because expand has already run, the global variable should have been
-		// checked for existence. handle if user has explicitly passed
-		// ((var.does_not_exit)) or ((not_a_varsrc.not_a_var))
-		if varSrc == "var" {
-			if val, ok := bc.Config.Vars[varName]; ok {
-				valString, ok := val.(string)
-				if ok {
-					return valString, nil
-				}
-				return "", fmt.Errorf("the global variable %s is not a string", inputReference)
-			}
-		}
+	value, err := bc.getValue(inputReference)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case SensitiveValue:
+		return v.Reveal(), nil
+	default:
+		return "", fmt.Errorf("the value %v is not a string", inputReference)
 	}
-	return "", fmt.Errorf("the value %s is not a global variable or was not defined", inputReference)
 }
@@ -0,0 +1,49 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import "testing"
+
+// TestValidateDowngradesNonValidatorDiagnostics proves that ValidationLevel
+// is honored for every diagnostic validate() produces, not just validator
+// failures: a vars-stage error (vars.labels must be a map) must come back
+// as a Warning under --validation-level WARNING.
+func TestValidateDowngradesNonValidatorDiagnostics(t *testing.T) {
+	bc := BlueprintConfig{Config: YamlConfig{
+		ValidationLevel: validationWarning,
+		Vars: map[string]interface{}{
+			"project_id": "my-project",
+			"labels":     "not-a-map",
+		},
+	}}
+
+	diags := bc.validate()
+
+	found := false
+	for _, d := range diags {
+		if d.Path != "vars.labels" {
+			continue
+		}
+		found = true
+		if d.Severity != SeverityWarning {
+			t.Errorf("vars.labels diagnostic severity = %v, want %v (downgraded under WARNING level)", d.Severity, SeverityWarning)
+		}
+	}
+	if !found {
+		t.Fatal("expected a vars.labels diagnostic, found none")
+	}
+}
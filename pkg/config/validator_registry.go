@@ -0,0 +1,199 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+// Validator is implemented by any check that blueprint validation can run.
+// The built-in GCP checks as well as validators contributed by out-of-tree
+// packages implement this interface, so the core validation engine does not
+// need a code change to add a new check.
+type Validator interface {
+	Name() string
+	Run(ctx context.Context, inputs map[string]interface{}) error
+}
+
+// ValidatorFactory builds a Validator bound to the blueprint being
+// validated. Packages that ship their own validators, including packages
+// outside this module, register a factory under the name that blueprints
+// will use in their `validator:` field.
+type ValidatorFactory func(bc *BlueprintConfig) Validator
+
+var externalValidators = map[string]ValidatorFactory{}
+
+// RegisterValidator makes a custom validator available to blueprints under
+// the given name. It is meant to be called from the init() function of a
+// package that ships its own validators.
+func RegisterValidator(name string, factory ValidatorFactory) {
+	if _, ok := externalValidators[name]; ok {
+		panic(fmt.Sprintf("config: RegisterValidator called twice for %s", name))
+	}
+	externalValidators[name] = factory
+}
+
+// funcValidator adapts the legacy func(validatorConfig) error signature used
+// by the built-in GCP checks to the Validator interface.
+type funcValidator struct {
+	name string
+	run  func(validatorConfig) error
+}
+
+func (f funcValidator) Name() string { return f.name }
+
+func (f funcValidator) Run(ctx context.Context, inputs map[string]interface{}) error {
+	return f.run(validatorConfig{Validator: f.name, Inputs: inputs})
+}
+
+// builtinValidators returns the Validator-wrapped equivalents of the GCP
+// checks that ship with ghpc.
+func (bc *BlueprintConfig) builtinValidators() []Validator {
+	return []Validator{
+		funcValidator{testProjectExistsName.String(), bc.testProjectExists},
+		funcValidator{testRegionExistsName.String(), bc.testRegionExists},
+		funcValidator{testZoneExistsName.String(), bc.testZoneExists},
+		funcValidator{testZoneInRegionName.String(), bc.testZoneInRegion},
+		funcValidator{"test_network_exists", bc.testNetworkExists},
+		funcValidator{"test_subnetwork_exists", bc.testSubnetworkExists},
+		funcValidator{"test_iam_role_exists", bc.testIamRoleExists},
+		funcValidator{"test_quota_available", bc.testQuotaAvailable},
+		funcValidator{"test_apis_enabled", bc.testAPIsEnabled},
+		funcValidator{"test_ssh_key_valid", bc.testSSHKeyValid},
+	}
+}
+
+// execMetaKeys are the Inputs entries that newDeclaredValidator consumes to
+// construct the exec validator itself (the command and its fixed args), as
+// opposed to the blueprint inputs that should be passed through to the
+// command as flags.
+var execMetaKeys = map[string]bool{"command": true, "args": true}
+
+// execValidator shells out to an external command and treats a non-zero
+// exit code as a validation failure. It backs the `validator: exec` entry
+// in a blueprint.
+type execValidator struct {
+	name    string
+	command string
+	args    []string
+	inputs  map[string]interface{}
+}
+
+func (v execValidator) Name() string { return v.name }
+
+func (v execValidator) Run(ctx context.Context, inputs map[string]interface{}) error {
+	args := append([]string{}, v.args...)
+	for k, val := range v.inputs {
+		args = append(args, fmt.Sprintf("--%s=%v", k, val))
+	}
+	cmd := exec.CommandContext(ctx, v.command, args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("exec validator %s failed: %v\n%s", v.name, err, out)
+	}
+	return nil
+}
+
+// httpMetaKeys are the Inputs entries that newDeclaredValidator consumes to
+// construct the http validator itself (the endpoint URL), as opposed to the
+// blueprint inputs that should be sent with the request.
+var httpMetaKeys = map[string]bool{"url": true}
+
+// httpValidator calls an external HTTP endpoint and treats any non-2xx
+// response as a validation failure. It backs the `validator: http` entry in
+// a blueprint. The blueprint's inputs (minus the "url" meta key) are sent as
+// the JSON request body.
+type httpValidator struct {
+	name   string
+	url    string
+	inputs map[string]interface{}
+}
+
+func (v httpValidator) Name() string { return v.name }
+
+func (v httpValidator) Run(ctx context.Context, inputs map[string]interface{}) error {
+	body, err := json.Marshal(v.inputs)
+	if err != nil {
+		return fmt.Errorf("http validator %s: failed to encode inputs: %w", v.name, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http validator %s: %w", v.name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http validator %s: %w", v.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("http validator %s: endpoint returned status %d", v.name, resp.StatusCode)
+	}
+	return nil
+}
+
+// newDeclaredValidator builds an exec or http Validator from a blueprint
+// entry whose Validator field is "exec" or "http", reading the command/url
+// and arguments out of Inputs so that no core code change is required to
+// add a new check. Any remaining Inputs, once the keys used to construct the
+// validator itself are stripped out, are passed through to the command/
+// endpoint at Run time.
+func newDeclaredValidator(vc validatorConfig) (Validator, error) {
+	switch vc.Validator {
+	case "exec":
+		command, ok := vc.Inputs["command"].(string)
+		if !ok || command == "" {
+			return nil, fmt.Errorf("validator: exec requires a string \"command\" input")
+		}
+		var args []string
+		if rawArgs, ok := vc.Inputs["args"].([]interface{}); ok {
+			for _, a := range rawArgs {
+				args = append(args, fmt.Sprintf("%v", a))
+			}
+		}
+		inputs := map[string]interface{}{}
+		for k, val := range vc.Inputs {
+			if execMetaKeys[k] {
+				continue
+			}
+			inputs[k] = val
+		}
+		return execValidator{name: "exec:" + command, command: command, args: args, inputs: inputs}, nil
+	case "http":
+		url, ok := vc.Inputs["url"].(string)
+		if !ok || url == "" {
+			return nil, fmt.Errorf("validator: http requires a string \"url\" input")
+		}
+		inputs := map[string]interface{}{}
+		for k, val := range vc.Inputs {
+			if httpMetaKeys[k] {
+				continue
+			}
+			inputs[k] = val
+		}
+		return httpValidator{name: "http:" + url, url: url, inputs: inputs}, nil
+	default:
+		return nil, fmt.Errorf("%s is not an implemented validator", vc.Validator)
+	}
+}
@@ -0,0 +1,122 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewDeclaredValidatorExecStripsMetaKeys(t *testing.T) {
+	vc := validatorConfig{
+		Validator: "exec",
+		Inputs: map[string]interface{}{
+			"command": "echo",
+			"args":    []interface{}{"hello"},
+			"region":  "us-east1",
+		},
+	}
+
+	v, err := newDeclaredValidator(vc)
+	if err != nil {
+		t.Fatalf("newDeclaredValidator returned error: %v", err)
+	}
+	ev, ok := v.(execValidator)
+	if !ok {
+		t.Fatalf("expected an execValidator, got %T", v)
+	}
+	if _, ok := ev.inputs["command"]; ok {
+		t.Error("expected \"command\" to be stripped from exec inputs")
+	}
+	if _, ok := ev.inputs["args"]; ok {
+		t.Error("expected \"args\" to be stripped from exec inputs")
+	}
+	if ev.inputs["region"] != "us-east1" {
+		t.Errorf("expected the real input \"region\" to survive, got %v", ev.inputs["region"])
+	}
+}
+
+func TestExecValidatorRunUsesOnlyRealInputs(t *testing.T) {
+	ev := execValidator{
+		name:    "exec:sh",
+		command: "sh",
+		args:    []string{"-c", `[ "$#" -eq 1 ] && [ "$1" = "--region=us-east1" ]`, "sh"},
+		inputs:  map[string]interface{}{"region": "us-east1"},
+	}
+	if err := ev.Run(context.Background(), nil); err != nil {
+		t.Errorf("Run returned error: %v", err)
+	}
+}
+
+func TestNewDeclaredValidatorHTTPStripsMetaKeys(t *testing.T) {
+	vc := validatorConfig{
+		Validator: "http",
+		Inputs: map[string]interface{}{
+			"url":     "https://example.com/validate",
+			"project": "my-project",
+		},
+	}
+
+	v, err := newDeclaredValidator(vc)
+	if err != nil {
+		t.Fatalf("newDeclaredValidator returned error: %v", err)
+	}
+	hv, ok := v.(httpValidator)
+	if !ok {
+		t.Fatalf("expected an httpValidator, got %T", v)
+	}
+	if _, ok := hv.inputs["url"]; ok {
+		t.Error("expected \"url\" to be stripped from http inputs")
+	}
+	if hv.inputs["project"] != "my-project" {
+		t.Errorf("expected the real input \"project\" to survive, got %v", hv.inputs["project"])
+	}
+}
+
+func TestHTTPValidatorRunSendsInputsAsJSONBody(t *testing.T) {
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("server failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hv := httpValidator{name: "http:test", url: server.URL, inputs: map[string]interface{}{"project": "my-project"}}
+	if err := hv.Run(context.Background(), nil); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if gotBody["project"] != "my-project" {
+		t.Errorf("server received body %v, want inputs to include project=my-project", gotBody)
+	}
+}
+
+func TestHTTPValidatorRunNonSuccessStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	hv := httpValidator{name: "http:test", url: server.URL, inputs: map[string]interface{}{}}
+	if err := hv.Run(context.Background(), nil); err == nil {
+		t.Error("expected a non-2xx response to return an error")
+	}
+}
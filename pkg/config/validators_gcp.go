@@ -0,0 +1,197 @@
+/**
+ * Copyright 2021 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+
+	"hpc-toolkit/pkg/validators"
+
+	"github.com/pkg/errors"
+)
+
+// testNetworkExists checks that the named VPC network exists in the project.
+func (bc *BlueprintConfig) testNetworkExists(validator validatorConfig) error {
+	requiredInputs := []string{"project_id", "network_name"}
+	funcName := "test_network_exists"
+	funcErrorMsg := fmt.Sprintf("validator %s failed", funcName)
+
+	err := testInputList(validator.Validator, validator.Inputs, requiredInputs)
+	if err != nil {
+		return err
+	}
+
+	projectID, err := bc.getStringValue(validator.Inputs["project_id"])
+	if err != nil {
+		return err
+	}
+	networkName, err := bc.getStringValue(validator.Inputs["network_name"])
+	if err != nil {
+		return err
+	}
+
+	if err := validators.TestNetworkExists(projectID, networkName); err != nil {
+		return errors.Wrap(err, funcErrorMsg)
+	}
+	return nil
+}
+
+// testSubnetworkExists checks that the named subnetwork exists in the
+// project and region.
+func (bc *BlueprintConfig) testSubnetworkExists(validator validatorConfig) error {
+	requiredInputs := []string{"project_id", "region", "subnetwork_name"}
+	funcName := "test_subnetwork_exists"
+	funcErrorMsg := fmt.Sprintf("validator %s failed", funcName)
+
+	err := testInputList(validator.Validator, validator.Inputs, requiredInputs)
+	if err != nil {
+		return err
+	}
+
+	projectID, err := bc.getStringValue(validator.Inputs["project_id"])
+	if err != nil {
+		return err
+	}
+	region, err := bc.getStringValue(validator.Inputs["region"])
+	if err != nil {
+		return err
+	}
+	subnetworkName, err := bc.getStringValue(validator.Inputs["subnetwork_name"])
+	if err != nil {
+		return err
+	}
+
+	if err := validators.TestSubnetworkExists(projectID, region, subnetworkName); err != nil {
+		return errors.Wrap(err, funcErrorMsg)
+	}
+	return nil
+}
+
+// testIamRoleExists checks that the calling principal holds the given IAM
+// role on the project.
+func (bc *BlueprintConfig) testIamRoleExists(validator validatorConfig) error {
+	requiredInputs := []string{"project_id", "role"}
+	funcName := "test_iam_role_exists"
+	funcErrorMsg := fmt.Sprintf("validator %s failed", funcName)
+
+	err := testInputList(validator.Validator, validator.Inputs, requiredInputs)
+	if err != nil {
+		return err
+	}
+
+	projectID, err := bc.getStringValue(validator.Inputs["project_id"])
+	if err != nil {
+		return err
+	}
+	role, err := bc.getStringValue(validator.Inputs["role"])
+	if err != nil {
+		return err
+	}
+
+	if err := validators.TestCallerHasRole(projectID, role); err != nil {
+		return errors.Wrap(err, funcErrorMsg)
+	}
+	return nil
+}
+
+// testQuotaAvailable checks that the project has enough headroom left on a
+// named quota metric (e.g. CPUS, NVIDIA_T4_GPUS) in a region.
+func (bc *BlueprintConfig) testQuotaAvailable(validator validatorConfig) error {
+	requiredInputs := []string{"project_id", "region", "metric", "required"}
+	funcName := "test_quota_available"
+	funcErrorMsg := fmt.Sprintf("validator %s failed", funcName)
+
+	err := testInputList(validator.Validator, validator.Inputs, requiredInputs)
+	if err != nil {
+		return err
+	}
+
+	projectID, err := bc.getStringValue(validator.Inputs["project_id"])
+	if err != nil {
+		return err
+	}
+	region, err := bc.getStringValue(validator.Inputs["region"])
+	if err != nil {
+		return err
+	}
+	metric, err := bc.getStringValue(validator.Inputs["metric"])
+	if err != nil {
+		return err
+	}
+	required, ok := toFloat(validator.Inputs["required"])
+	if !ok {
+		return fmt.Errorf("validator %s: \"required\" must be a number", funcName)
+	}
+
+	if err := validators.TestQuotaAvailable(projectID, region, metric, required); err != nil {
+		return errors.Wrap(err, funcErrorMsg)
+	}
+	return nil
+}
+
+// testAPIsEnabled checks that the given service APIs are enabled on the
+// project.
+func (bc *BlueprintConfig) testAPIsEnabled(validator validatorConfig) error {
+	requiredInputs := []string{"project_id", "services"}
+	funcName := "test_apis_enabled"
+	funcErrorMsg := fmt.Sprintf("validator %s failed", funcName)
+
+	err := testInputList(validator.Validator, validator.Inputs, requiredInputs)
+	if err != nil {
+		return err
+	}
+
+	projectID, err := bc.getStringValue(validator.Inputs["project_id"])
+	if err != nil {
+		return err
+	}
+	rawServices, ok := validator.Inputs["services"].([]interface{})
+	if !ok {
+		return fmt.Errorf("validator %s: \"services\" must be a list of strings", funcName)
+	}
+	services := make([]string, len(rawServices))
+	for i, s := range rawServices {
+		services[i] = fmt.Sprintf("%v", s)
+	}
+
+	if err := validators.TestAPIsEnabled(projectID, services); err != nil {
+		return errors.Wrap(err, funcErrorMsg)
+	}
+	return nil
+}
+
+// testSSHKeyValid checks that an SSH public key is well-formed.
+func (bc *BlueprintConfig) testSSHKeyValid(validator validatorConfig) error {
+	requiredInputs := []string{"ssh_public_key"}
+	funcName := "test_ssh_key_valid"
+	funcErrorMsg := fmt.Sprintf("validator %s failed", funcName)
+
+	err := testInputList(validator.Validator, validator.Inputs, requiredInputs)
+	if err != nil {
+		return err
+	}
+
+	sshKey, err := bc.getStringValue(validator.Inputs["ssh_public_key"])
+	if err != nil {
+		return err
+	}
+
+	if err := validators.TestSSHKeyValid(sshKey); err != nil {
+		return errors.Wrap(err, funcErrorMsg)
+	}
+	return nil
+}